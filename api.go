@@ -0,0 +1,322 @@
+// Copyright (c) 2012-2013 Jason McVetta.  This is Free Software, released
+// under the terms of the GPL v3.  See http://www.gnu.org/copyleft/gpl.html for
+// details.  Resist intellectual serfdom - the ownership of ideas is akin to
+// slavery.
+
+// Package napping is a client library for interacting with RESTful APIs.
+package napping
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// UnexpectedStatus is returned when a response's status code does not match
+// the Opts.ExpectedStatus supplied by the caller.
+var UnexpectedStatus = errors.New("napping: unexpected HTTP status code")
+
+// Params is a map of URL query parameters.
+type Params map[string]string
+
+// Encode turns Params into a URL-encoded query string.
+func (p Params) Encode() string {
+	v := url.Values{}
+	for key, val := range p {
+		v.Set(key, val)
+	}
+	return v.Encode()
+}
+
+// Opts holds per-request options.
+type Opts struct {
+	// ExpectedStatus, if nonzero, causes Send to return UnexpectedStatus
+	// when the response status code does not match.
+	ExpectedStatus int
+	// Header, if set, is merged into the outgoing request's headers.
+	Header *http.Header
+	// RequestCodec, if set, overrides the codec used to marshal the
+	// payload and overrides the codec inferred from the Content-Type
+	// header.
+	RequestCodec Codec
+	// ResponseCodec, if set, overrides the codec used to unmarshal the
+	// response body, regardless of the server's Content-Type.
+	ResponseCodec Codec
+	// Compress, if true, gzip-encodes the request payload. It is ORed
+	// with Session.Compress, so either one enables compression.
+	Compress bool
+	// CompressMinBytes overrides Session.CompressMinBytes for this
+	// request when positive.
+	CompressMinBytes int
+	// Retry, if set, overrides Session.Retry for this request.
+	Retry *RetryPolicy
+}
+
+// Response captures the body and status of an HTTP response so that it may
+// be inspected or unmarshalled after the fact.
+type Response struct {
+	status int
+	bytes  []byte
+	header http.Header
+	codec  Codec
+}
+
+// Status returns the HTTP status code of the response.
+func (r *Response) Status() int {
+	return r.status
+}
+
+// Header returns the response's HTTP headers.
+func (r *Response) Header() http.Header {
+	return r.header
+}
+
+// RawText returns the response body as a string.
+func (r *Response) RawText() string {
+	return string(r.bytes)
+}
+
+// Unmarshall decodes the response body into v with the codec send chose for
+// the result param: Opts.ResponseCodec, else the codec matching the
+// response's Content-Type, else the request codec. A Response that did not
+// pass through send (such as one returned by Session.Send) has no codec
+// recorded and is decoded as JSON, whatever its Content-Type.
+func (r *Response) Unmarshall(v interface{}) error {
+	if r.codec != nil {
+		return r.codec.Unmarshal(r.bytes, v)
+	}
+	return json.Unmarshal(r.bytes, v)
+}
+
+// Session maintains state - such as an HTTP client and default headers -
+// across multiple requests.
+type Session struct {
+	Client *http.Client
+	Header *http.Header
+	// Log, when true, causes each request and response to be logged.
+	Log bool
+	// Codecs overrides or extends the default set of wire-format codecs
+	// (see DefaultCodecs) keyed by Content-Type. A nil map uses the
+	// defaults unmodified.
+	Codecs map[string]Codec
+	// Compress, if true, gzip-encodes request payloads at or above
+	// CompressMinBytes. See Opts.Compress to enable it per-request.
+	Compress bool
+	// CompressMinBytes is the smallest payload, in bytes, that will be
+	// gzip-encoded when compression is enabled. Zero compresses every
+	// payload.
+	CompressMinBytes int
+	// NoAcceptEncoding, if true, suppresses the "Accept-Encoding: gzip"
+	// header napping otherwise sends on every request.
+	NoAcceptEncoding bool
+	// Retry, if set, retries failed requests per the policy. See
+	// Opts.Retry to set a policy for a single request.
+	Retry *RetryPolicy
+	// middleware is the chain installed via Use, run outermost-first
+	// around compression, retry, and the network transport.
+	middleware []Middleware
+}
+
+// Get sends a GET request using a transient Session.
+func Get(url string, p *Params, result interface{}, opts *Opts) (*Response, error) {
+	s := Session{}
+	return s.Get(url, p, result, opts)
+}
+
+// Post sends a POST request using a transient Session.
+func Post(url string, payload, result interface{}, opts *Opts) (*Response, error) {
+	s := Session{}
+	return s.Post(url, payload, result, opts)
+}
+
+// Put sends a PUT request using a transient Session.
+func Put(url string, payload, result interface{}, opts *Opts) (*Response, error) {
+	s := Session{}
+	return s.Put(url, payload, result, opts)
+}
+
+// Delete sends a DELETE request using a transient Session.
+func Delete(url string, p *Params, result interface{}, opts *Opts) (*Response, error) {
+	s := Session{}
+	return s.Delete(url, p, result, opts)
+}
+
+// Get sends a GET request.
+func (s *Session) Get(u string, p *Params, result interface{}, opts *Opts) (*Response, error) {
+	if p != nil {
+		q := p.Encode()
+		if q != "" {
+			if bytes.ContainsRune([]byte(u), '?') {
+				u = u + "&" + q
+			} else {
+				u = u + "?" + q
+			}
+		}
+	}
+	return s.send("GET", u, nil, result, opts)
+}
+
+// Post sends a POST request with payload as the body, marshalled with the
+// codec send selects (JSON by default).
+func (s *Session) Post(u string, payload, result interface{}, opts *Opts) (*Response, error) {
+	return s.send("POST", u, payload, result, opts)
+}
+
+// Put sends a PUT request with payload as the body, marshalled with the
+// codec send selects (JSON by default).
+func (s *Session) Put(u string, payload, result interface{}, opts *Opts) (*Response, error) {
+	return s.send("PUT", u, payload, result, opts)
+}
+
+// Delete sends a DELETE request.
+func (s *Session) Delete(u string, p *Params, result interface{}, opts *Opts) (*Response, error) {
+	if p != nil {
+		q := p.Encode()
+		if q != "" {
+			if bytes.ContainsRune([]byte(u), '?') {
+				u = u + "&" + q
+			} else {
+				u = u + "?" + q
+			}
+		}
+	}
+	return s.send("DELETE", u, nil, result, opts)
+}
+
+// send marshals payload, runs the resulting Request through the Session's
+// middleware chain (see Use and Send), and unmarshals the response body
+// into result. The payload is marshalled with the codec selected by
+// opts.RequestCodec or, failing that, by the outgoing Content-Type header;
+// the response is unmarshalled with opts.ResponseCodec or, failing that, by
+// the codec matching the server's Content-Type, falling back to the
+// request codec.
+func (s *Session) send(method, u string, payload, result interface{}, opts *Opts) (*Response, error) {
+	reqCodec := Codec(jsonCodec{})
+	contentType := reqCodec.ContentType()
+	if opts != nil && opts.RequestCodec != nil {
+		// An explicit RequestCodec overrides any Content-Type header,
+		// so the body is marshalled and labelled consistently.
+		reqCodec = opts.RequestCodec
+		contentType = reqCodec.ContentType()
+	} else if ct := headerContentType(s.Header, opts); ct != "" {
+		contentType = ct
+		if c, ok := s.codec(ct); ok {
+			reqCodec = c
+		}
+	}
+
+	var body []byte
+	if payload != nil {
+		b, err := reqCodec.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+
+	header := http.Header{}
+	if len(body) > 0 {
+		header.Set("Content-Type", contentType)
+	}
+	if s.Header != nil {
+		for k, v := range *s.Header {
+			header[k] = v
+		}
+	}
+	if opts != nil && opts.Header != nil {
+		for k, v := range *opts.Header {
+			header[k] = v
+		}
+	}
+	if opts != nil && opts.RequestCodec != nil && len(body) > 0 {
+		// Re-assert the codec's Content-Type in case it was clobbered by
+		// a Content-Type set on Session.Header or Opts.Header.
+		header.Set("Content-Type", contentType)
+	}
+
+	req := &Request{Method: method, URL: u, Header: header, Body: body}
+	resp, err := s.dispatch(req, opts)
+	if err != nil {
+		return resp, err
+	}
+
+	respCodec := reqCodec
+	if opts != nil && opts.ResponseCodec != nil {
+		respCodec = opts.ResponseCodec
+	} else if ct := resp.header.Get("Content-Type"); ct != "" {
+		if c, ok := s.codec(ct); ok {
+			respCodec = c
+		}
+	}
+	resp.codec = respCodec
+
+	if opts != nil && opts.ExpectedStatus != 0 && resp.status != opts.ExpectedStatus {
+		return resp, UnexpectedStatus
+	}
+	if result != nil && len(resp.bytes) > 0 {
+		if err := respCodec.Unmarshal(resp.bytes, result); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// transport returns the terminal RoundTrip that performs the actual network
+// request: it builds an *http.Request from req, executes it, and reads and
+// decompresses the response.
+func (s *Session) transport() RoundTrip {
+	return func(req *Request) (*Response, error) {
+		httpReq, err := http.NewRequest(req.Method, req.URL, bytes.NewReader(req.Body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range req.Header {
+			httpReq.Header[k] = v
+		}
+		if !s.NoAcceptEncoding && httpReq.Header.Get("Accept-Encoding") == "" {
+			httpReq.Header.Set("Accept-Encoding", "gzip")
+		}
+		client := s.Client
+		if client == nil {
+			client = &http.Client{}
+		}
+		rsp, err := client.Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		defer rsp.Body.Close()
+		data, err := ioutil.ReadAll(rsp.Body)
+		if err != nil {
+			return nil, err
+		}
+		data, err = decompressBody(data, rsp.Header.Get("Content-Encoding"))
+		if err != nil {
+			return nil, err
+		}
+		resp := &Response{
+			status: rsp.StatusCode,
+			bytes:  data,
+			header: rsp.Header,
+		}
+		return resp, nil
+	}
+}
+
+// headerContentType returns the Content-Type set in opts.Header, falling
+// back to sessionHeader, or "" if neither sets it.
+func headerContentType(sessionHeader *http.Header, opts *Opts) string {
+	if opts != nil && opts.Header != nil {
+		if v := opts.Header.Get("Content-Type"); v != "" {
+			return v
+		}
+	}
+	if sessionHeader != nil {
+		if v := sessionHeader.Get("Content-Type"); v != "" {
+			return v
+		}
+	}
+	return ""
+}
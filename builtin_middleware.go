@@ -0,0 +1,138 @@
+package napping
+
+import (
+	"encoding/base64"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BasicAuth returns a Middleware that sets HTTP Basic Authentication on
+// every outgoing request.
+func BasicAuth(username, password string) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			setHeader(req, "Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+			return next(req)
+		}
+	}
+}
+
+// BearerAuth returns a Middleware that sets an "Authorization: Bearer
+// <token>" header on every outgoing request.
+func BearerAuth(token string) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			setHeader(req, "Authorization", "Bearer "+token)
+			return next(req)
+		}
+	}
+}
+
+func setHeader(req *Request, key, value string) {
+	if req.Header == nil {
+		req.Header = make(map[string][]string)
+	}
+	req.Header.Set(key, value)
+}
+
+// Logging returns a Middleware that logs each request and response via
+// logger. Session.Log installs this same middleware, using the standard
+// logger, at a fixed position next to the transport; Use Logging directly
+// to pick a custom logger or a different position in the chain.
+func Logging(logger *log.Logger) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			logger.Println(req.Method, req.URL)
+			resp, err := next(req)
+			if err != nil {
+				logger.Println(req.Method, req.URL, "error:", err)
+				return resp, err
+			}
+			logger.Println(resp.Status(), resp.RawText())
+			return resp, err
+		}
+	}
+}
+
+// RedactBody returns a Middleware that logs each request and response via
+// logger with any of words replaced by "[REDACTED]". It does not alter the
+// bytes actually sent or received - only what reaches logger.
+func RedactBody(logger *log.Logger, words ...string) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			logger.Println(req.Method, req.URL, redact(string(req.Body), words))
+			resp, err := next(req)
+			if resp != nil {
+				logger.Println(resp.Status(), redact(resp.RawText(), words))
+			}
+			return resp, err
+		}
+	}
+}
+
+func redact(body string, words []string) string {
+	for _, w := range words {
+		if w == "" {
+			continue
+		}
+		body = strings.ReplaceAll(body, w, "[REDACTED]")
+	}
+	return body
+}
+
+// RateLimit returns a Middleware that allows at most one request per host
+// every interval, blocking the caller's goroutine until its turn.
+func RateLimit(interval time.Duration) Middleware {
+	var mu sync.Mutex
+	last := make(map[string]time.Time)
+	return func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			host := requestHost(req.URL)
+			mu.Lock()
+			for {
+				prev, ok := last[host]
+				if !ok {
+					break
+				}
+				wait := interval - time.Since(prev)
+				if wait <= 0 {
+					break
+				}
+				mu.Unlock()
+				time.Sleep(wait)
+				mu.Lock()
+			}
+			last[host] = time.Now()
+			mu.Unlock()
+			return next(req)
+		}
+	}
+}
+
+func requestHost(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	return u.Host
+}
+
+// Metrics returns a Middleware that invokes fn after every request with the
+// method, URL, response status (0 on transport error) and duration.
+func Metrics(fn func(method, url string, status int, dur time.Duration)) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			status := 0
+			if resp != nil {
+				status = resp.Status()
+			}
+			fn(req.Method, req.URL, status, time.Since(start))
+			return resp, err
+		}
+	}
+}
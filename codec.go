@@ -0,0 +1,106 @@
+package napping
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// MIME content types understood by napping's built-in codecs.
+const (
+	MIMEApplicationJSON = "application/json"
+	MIMEApplicationXML  = "application/xml"
+	MIMETextXML         = "text/xml"
+	MIMEApplicationForm = "application/x-www-form-urlencoded"
+)
+
+// Codec marshals request payloads and unmarshals response bodies for a
+// particular wire format. Session.Codecs maps a Content-Type to the Codec
+// that handles it; Opts.RequestCodec and Opts.ResponseCodec override the
+// codec used for a single call.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	ContentType() string
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) ContentType() string                        { return MIMEApplicationJSON }
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error)      { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+func (xmlCodec) ContentType() string                        { return MIMEApplicationXML }
+
+type formCodec struct{}
+
+func (formCodec) Marshal(v interface{}) ([]byte, error) {
+	switch t := v.(type) {
+	case Params:
+		return []byte(t.Encode()), nil
+	case *Params:
+		return []byte(t.Encode()), nil
+	default:
+		return nil, errors.New("napping: form codec requires a Params payload")
+	}
+}
+
+func (formCodec) Unmarshal(data []byte, v interface{}) error {
+	p, ok := v.(*Params)
+	if !ok {
+		return errors.New("napping: form codec can only unmarshal into *Params")
+	}
+	vals, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	if *p == nil {
+		*p = Params{}
+	}
+	for key := range vals {
+		(*p)[key] = vals.Get(key)
+	}
+	return nil
+}
+
+func (formCodec) ContentType() string { return MIMEApplicationForm }
+
+// DefaultCodecs returns the set of codecs napping registers on every Session
+// unless overridden via Session.Codecs.
+func DefaultCodecs() map[string]Codec {
+	return map[string]Codec{
+		MIMEApplicationJSON: jsonCodec{},
+		MIMEApplicationXML:  xmlCodec{},
+		MIMETextXML:         xmlCodec{},
+		MIMEApplicationForm: formCodec{},
+	}
+}
+
+var defaultCodecs = DefaultCodecs()
+
+// codec resolves the Codec registered for contentType, preferring
+// Session.Codecs over the built-in defaults. The charset/boundary portion of
+// contentType, if any, is ignored.
+func (s *Session) codec(contentType string) (Codec, bool) {
+	ct := baseContentType(contentType)
+	if s.Codecs != nil {
+		if c, ok := s.Codecs[ct]; ok {
+			return c, true
+		}
+	}
+	c, ok := defaultCodecs[ct]
+	return c, ok
+}
+
+func baseContentType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		contentType = contentType[:i]
+	}
+	return strings.TrimSpace(contentType)
+}
@@ -0,0 +1,166 @@
+package napping
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+type xmlStruct struct {
+	Foo int    `xml:"Foo"`
+	Bar string `xml:"Bar"`
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	cases := []struct {
+		name        string
+		contentType string
+	}{
+		{"json", MIMEApplicationJSON},
+		{"xml", MIMEApplicationXML},
+		{"form", MIMEApplicationForm},
+	}
+	for _, c := range cases {
+		codec, ok := defaultCodecs[c.contentType]
+		if !ok {
+			t.Fatalf("%s: no default codec registered", c.name)
+		}
+		var payload interface{}
+		var result interface{}
+		switch c.contentType {
+		case MIMEApplicationForm:
+			payload = Params{"foo": "111", "bar": "foo"}
+			result = &Params{}
+		case MIMEApplicationXML:
+			payload = xmlStruct{Foo: 111, Bar: "foo"}
+			result = &xmlStruct{}
+		default:
+			payload = fooStruct
+			result = &structType{}
+		}
+		blob, err := codec.Marshal(payload)
+		if err != nil {
+			t.Fatalf("%s: Marshal: %s", c.name, err)
+		}
+		if err := codec.Unmarshal(blob, result); err != nil {
+			t.Fatalf("%s: Unmarshal: %s", c.name, err)
+		}
+		switch c.contentType {
+		case MIMEApplicationForm:
+			assert.Equal(t, Params{"foo": "111", "bar": "foo"}, *result.(*Params))
+		case MIMEApplicationXML:
+			assert.Equal(t, xmlStruct{Foo: 111, Bar: "foo"}, *result.(*xmlStruct))
+		default:
+			assert.Equal(t, fooStruct, *result.(*structType))
+		}
+	}
+}
+
+func HandleGetXML(w http.ResponseWriter, req *http.Request) {
+	blob, err := xmlCodec{}.Marshal(xmlStruct{Foo: 222, Bar: "bar"})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", MIMEApplicationXML)
+	w.Write(blob)
+}
+
+func TestResponseContentNegotiation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleGetXML))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	res := xmlStruct{}
+	resp, err := Get(url, nil, &res, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, resp.Status())
+	assert.Equal(t, xmlStruct{Foo: 222, Bar: "bar"}, res)
+}
+
+// TestResponseUnmarshallUsesContentType confirms that Response.Unmarshall,
+// called directly rather than through the result param, decodes with the
+// codec matching the response's Content-Type instead of always assuming
+// JSON.
+func TestResponseUnmarshallUsesContentType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleGetXML))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+
+	resp, err := Get(url, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := xmlStruct{}
+	if err := resp.Unmarshall(&res); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, xmlStruct{Foo: 222, Bar: "bar"}, res)
+}
+
+func HandlePostForm(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.FormValue("foo") != "bar" {
+		http.Error(w, "missing form field", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", MIMEApplicationJSON)
+	w.Write([]byte(`{"Foo":1,"Bar":"ok"}`))
+}
+
+// TestRequestCodecOverridesContentTypeHeader confirms that an explicit
+// Opts.RequestCodec wins over a Content-Type header set on the Session,
+// marshalling and labelling the body consistently rather than silently
+// encoding as one format while claiming to be another.
+func TestRequestCodecOverridesContentTypeHeader(t *testing.T) {
+	var gotContentType string
+	var gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotContentType = req.Header.Get("Content-Type")
+		blob, _ := ioutil.ReadAll(req.Body)
+		gotBody = string(blob)
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+
+	h := http.Header{}
+	h.Set("Content-Type", MIMEApplicationJSON)
+	s := Session{Header: &h}
+	opts := Opts{RequestCodec: xmlCodec{}}
+	_, err := s.Post(url, xmlStruct{Foo: 111, Bar: "foo"}, nil, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, MIMEApplicationXML, gotContentType)
+	blob, err := xmlCodec{}.Marshal(xmlStruct{Foo: 111, Bar: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(blob), gotBody)
+}
+
+func TestFormEncodedParamsPayload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandlePostForm))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	payload := Params{"foo": "bar"}
+	res := structType{}
+	h := http.Header{}
+	h.Set("Content-Type", MIMEApplicationForm)
+	opts := Opts{Header: &h}
+	resp, err := Post(url, &payload, &res, &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, resp.Status())
+	assert.Equal(t, structType{Foo: 1, Bar: "ok"}, res)
+}
@@ -0,0 +1,86 @@
+package napping
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+)
+
+// Compress returns a Middleware that gzip-encodes req.Body when it is at
+// least minBytes, setting Content-Encoding accordingly. Install it with
+// Session.Use to control its position relative to your own middleware -
+// e.g. outside a signing middleware so the signature covers the compressed
+// bytes. Session.Compress/Opts.Compress install this same middleware
+// automatically at a fixed position for callers who don't need custom
+// ordering.
+func Compress(minBytes int) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			body, gzipped, err := gzipAtLeast(req.Body, minBytes)
+			if err != nil {
+				return nil, err
+			}
+			// Pass a copy on, so a Retry outside this middleware resends
+			// the uncompressed body rather than gzipping it a second time.
+			out := *req
+			out.Body = body
+			if gzipped {
+				out.Header = req.Header.Clone()
+				if out.Header == nil {
+					out.Header = http.Header{}
+				}
+				out.Header.Set("Content-Encoding", "gzip")
+			}
+			return next(&out)
+		}
+	}
+}
+
+// gzipAtLeast gzip-encodes data when its length is at least minBytes. It
+// reports whether data was compressed.
+func gzipAtLeast(data []byte, minBytes int) (compressed []byte, ok bool, err error) {
+	if len(data) == 0 || len(data) < minBytes {
+		return data, false, nil
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, false, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
+
+// decompressBody decodes data according to a response's Content-Encoding
+// header. Unrecognized or empty encodings are returned unchanged.
+func decompressBody(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case "deflate":
+		// HTTP's "deflate" Content-Encoding is zlib-wrapped (RFC 1950),
+		// not raw DEFLATE (RFC 1951), despite the name. Some servers
+		// send raw DEFLATE anyway, so fall back to that on zlib
+		// header failure.
+		r, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			fr := flate.NewReader(bytes.NewReader(data))
+			defer fr.Close()
+			return ioutil.ReadAll(fr)
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	default:
+		return data, nil
+	}
+}
@@ -0,0 +1,136 @@
+package napping
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func HandleCompressedPost(w http.ResponseWriter, req *http.Request) {
+	var body []byte
+	var err error
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, gerr := gzip.NewReader(req.Body)
+		if gerr != nil {
+			http.Error(w, gerr.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body, err = ioutil.ReadAll(gz)
+	} else {
+		body, err = ioutil.ReadAll(req.Body)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var s structType
+	if err := json.Unmarshal(body, &s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s != fooStruct {
+		http.Error(w, "bad request body", http.StatusBadRequest)
+		return
+	}
+	blob, _ := json.Marshal(barStruct)
+	if req.Header.Get("Accept-Encoding") == "gzip" {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(blob)
+		gz.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write(buf.Bytes())
+		return
+	}
+	w.Header().Set("Content-Type", MIMEApplicationJSON)
+	w.Write(blob)
+}
+
+func TestCompressedRequestAndResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleCompressedPost))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	s := Session{Compress: true}
+	res := structType{}
+	resp, err := s.Post(url, &fooStruct, &res, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, resp.Status())
+	assert.Equal(t, barStruct, res)
+	blob, _ := json.Marshal(barStruct)
+	assert.Equal(t, string(blob), resp.RawText())
+}
+
+func TestCompressMinBytesThreshold(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			t.Error("small payload should not have been compressed")
+		}
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	s := Session{Compress: true, CompressMinBytes: 1 << 20}
+	_, err := s.Post(url, &fooStruct, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func HandleZlibDeflateGet(w http.ResponseWriter, req *http.Request) {
+	blob, _ := json.Marshal(barStruct)
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(blob)
+	zw.Close()
+	w.Header().Set("Content-Encoding", "deflate")
+	w.Header().Set("Content-Type", MIMEApplicationJSON)
+	w.Write(buf.Bytes())
+}
+
+func TestDeflateResponseIsZlibWrapped(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleZlibDeflateGet))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	res := structType{}
+	resp, err := Get(url, nil, &res, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, resp.Status())
+	assert.Equal(t, barStruct, res)
+}
+
+func TestDeflateResponseFallsBackToRawFlate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		blob, _ := json.Marshal(barStruct)
+		var buf bytes.Buffer
+		fw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+		fw.Write(blob)
+		fw.Close()
+		w.Header().Set("Content-Encoding", "deflate")
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	res := structType{}
+	resp, err := Get(url, nil, &res, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, resp.Status())
+	assert.Equal(t, barStruct, res)
+}
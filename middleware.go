@@ -0,0 +1,86 @@
+package napping
+
+import (
+	"log"
+	"net/http"
+)
+
+// Request is the outbound request passed through a Session's middleware
+// chain. Body holds the already-marshalled (but not yet compressed)
+// payload; middleware may rewrite it, e.g. to compress or sign it.
+type Request struct {
+	Method string
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// RoundTrip performs a single request/response exchange.
+type RoundTrip func(*Request) (*Response, error)
+
+// Middleware wraps a RoundTrip to observe or modify requests and responses,
+// analogous to a func(http.Handler) http.Handler wrapper.
+type Middleware func(next RoundTrip) RoundTrip
+
+// Use appends mw to the Session's middleware chain. Middleware runs
+// outermost-first: the first Middleware passed to Use is the first to see
+// the request and the last to see the response.
+func (s *Session) Use(mw ...Middleware) {
+	s.middleware = append(s.middleware, mw...)
+}
+
+// Send runs req through the Session's middleware chain - in the order
+// installed via Use, then the built-in compression and retry behavior
+// configured via Session.Compress and Session.Retry - and finally the
+// network transport.
+func (s *Session) Send(req *Request) (*Response, error) {
+	return s.dispatch(req, nil)
+}
+
+// dispatch builds the full chain for req: user middleware (outermost)
+// wrapping compression and retry - both installed via the same Retry and
+// Compress constructors Use accepts, just at a fixed position - wrapping
+// logging (if Session.Log is set) and finally the network transport. opts,
+// if non-nil, overrides the session's Compress and Retry settings for this
+// call only.
+//
+// Callers who need retry, compression, or logging positioned elsewhere in
+// the chain (e.g. inside an auth-refresh middleware) should leave the
+// corresponding Session field unset and install Retry/Compress/Logging
+// themselves via Use.
+func (s *Session) dispatch(req *Request, opts *Opts) (*Response, error) {
+	rt := s.transport()
+	if s.Log {
+		rt = Logging(log.Default())(rt)
+	}
+
+	policy := s.Retry
+	if opts != nil && opts.Retry != nil {
+		policy = opts.Retry
+	}
+	if policy != nil {
+		if s.Log && policy.Logger == nil {
+			withLogger := *policy
+			withLogger.Logger = log.Default()
+			policy = &withLogger
+		}
+		rt = Retry(policy)(rt)
+	}
+
+	compress := s.Compress
+	if opts != nil && opts.Compress {
+		compress = true
+	}
+	if compress {
+		threshold := s.CompressMinBytes
+		if opts != nil && opts.CompressMinBytes > 0 {
+			threshold = opts.CompressMinBytes
+		}
+		rt = Compress(threshold)(rt)
+	}
+
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		rt = s.middleware[i](rt)
+	}
+	return rt(req)
+}
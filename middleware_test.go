@@ -0,0 +1,156 @@
+package napping
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestMiddlewareOrdering(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next RoundTrip) RoundTrip {
+			return func(req *Request) (*Response, error) {
+				order = append(order, name+":before")
+				resp, err := next(req)
+				order = append(order, name+":after")
+				return resp, err
+			}
+		}
+	}
+
+	s := Session{}
+	s.Use(trace("outer"), trace("inner"))
+	_, err := s.Get(url, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"outer:before", "inner:before", "inner:after", "outer:after"}, order)
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	calledNetwork := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calledNetwork = true
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+
+	synthetic := &Response{status: 200, bytes: []byte(`{"Foo":9,"Bar":"synthetic"}`)}
+	shortCircuit := func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			return synthetic, nil
+		}
+	}
+
+	s := Session{}
+	s.Use(shortCircuit)
+	res := structType{}
+	resp, err := s.Get(url, nil, &res, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, false, calledNetwork)
+	assert.Equal(t, 200, resp.Status())
+	assert.Equal(t, structType{Foo: 9, Bar: "synthetic"}, res)
+}
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotAuth = req.Header.Get("Authorization")
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+
+	s := Session{}
+	s.Use(BasicAuth("alice", "secret"))
+	_, err := s.Get(url, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Basic YWxpY2U6c2VjcmV0", gotAuth)
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+
+	var gotMethod, gotURL string
+	var gotStatus int
+	var gotDur time.Duration
+	s := Session{}
+	s.Use(Metrics(func(method, u string, status int, dur time.Duration) {
+		gotMethod, gotURL, gotStatus, gotDur = method, u, status, dur
+	}))
+	_, err := s.Get(url, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "GET", gotMethod)
+	assert.Equal(t, url, gotURL)
+	assert.Equal(t, 200, gotStatus)
+	if gotDur <= 0 {
+		t.Error("expected nonzero duration")
+	}
+}
+
+// TestRetryAndCompressComposeViaUse verifies that Retry and Compress, the
+// same middleware Session.Retry/Session.Compress install automatically, can
+// instead be installed directly via Use and positioned relative to custom
+// middleware - e.g. with a counter middleware placed inside the retry loop
+// so it observes every attempt, not just the first.
+func TestRetryAndCompressComposeViaUse(t *testing.T) {
+	var attempts, innerSeen int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&attempts, 1)
+		if req.Header.Get("Content-Encoding") != "gzip" {
+			t.Error("expected gzip-compressed request body")
+		}
+		if n < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+
+	countEachAttempt := func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			atomic.AddInt32(&innerSeen, 1)
+			return next(req)
+		}
+	}
+
+	s := Session{}
+	s.Use(
+		Retry(&RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, Multiplier: 1}),
+		countEachAttempt,
+		Compress(0),
+	)
+	_, err := s.Post(url, &fooStruct, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&innerSeen))
+}
@@ -0,0 +1,156 @@
+package napping
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Retry returns a Middleware that retries next per policy. Install it with
+// Session.Use to control its position relative to your own middleware -
+// e.g. inside an auth-refresh middleware so a refreshed token is used on
+// every retried attempt, not just the first. Session.Retry/Opts.Retry
+// install this same middleware automatically at a fixed position for
+// callers who don't need custom ordering. The request body was already
+// buffered by send, so it is safe to resend on each attempt.
+func Retry(policy *RetryPolicy) Middleware {
+	return func(next RoundTrip) RoundTrip {
+		return func(req *Request) (*Response, error) {
+			maxAttempts := 1
+			if policy.MaxAttempts > 0 {
+				maxAttempts = policy.MaxAttempts
+			}
+			start := time.Now()
+			var resp *Response
+			var err error
+			for attempt := 1; ; attempt++ {
+				resp, err = next(req)
+				if attempt >= maxAttempts || !policy.retryOn()(resp, err) {
+					break
+				}
+				delay := policy.backoff(attempt - 1)
+				if policy.RespectRetryAfter && resp != nil {
+					if d, ok := retryAfterDelay(resp.Header().Get("Retry-After")); ok {
+						delay = d
+					}
+				}
+				if policy.MaxElapsed > 0 && time.Since(start)+delay > policy.MaxElapsed {
+					break
+				}
+				if policy.Logger != nil {
+					policy.Logger.Printf("napping: %s %s attempt %d failed, retrying in %s", req.Method, req.URL, attempt, delay)
+				}
+				time.Sleep(delay)
+			}
+			return resp, err
+		}
+	}
+}
+
+// RetryPolicy controls whether, how often, and with what backoff a failed
+// request is retried. A nil *RetryPolicy disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of times a request is attempted,
+	// including the first. Values <= 0 are treated as 1.
+	MaxAttempts int
+	// InitialInterval is the backoff before the second attempt.
+	InitialInterval time.Duration
+	// MaxInterval caps the computed backoff before jitter is applied.
+	MaxInterval time.Duration
+	// MaxElapsed caps the total time spent retrying; once the elapsed
+	// time plus the next backoff would exceed it, retrying stops.
+	MaxElapsed time.Duration
+	// Multiplier is applied to InitialInterval for each subsequent
+	// attempt.
+	Multiplier float64
+	// RandomizationFactor jitters each computed delay by ±factor.
+	RandomizationFactor float64
+	// RetryOn reports whether a failed attempt should be retried. If
+	// nil, the default policy retries on transport errors and on HTTP
+	// 429, 502, 503 and 504.
+	RetryOn func(*Response, error) bool
+	// RespectRetryAfter, if true, uses a response's Retry-After header
+	// (seconds or an HTTP date) as the delay instead of the computed
+	// backoff.
+	RespectRetryAfter bool
+	// Logger, if set, receives a line for each retried attempt. Session.Retry
+	// sets this to the standard logger when Session.Log is true.
+	Logger *log.Logger
+}
+
+// DefaultRetryPolicy makes up to 5 attempts in all (the first plus up to 4
+// retries) on transient failures, backing off exponentially from 100ms with
+// the backoff capped at 10s before jitter, and stops once the next delay
+// would take the total past 30s.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:         5,
+		InitialInterval:     100 * time.Millisecond,
+		MaxInterval:         10 * time.Second,
+		MaxElapsed:          30 * time.Second,
+		Multiplier:          2,
+		RandomizationFactor: 0.5,
+		RespectRetryAfter:   true,
+	}
+}
+
+func defaultRetryOn(resp *Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	switch resp.Status() {
+	case 429, 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryOn() func(*Response, error) bool {
+	if p.RetryOn != nil {
+		return p.RetryOn
+	}
+	return defaultRetryOn
+}
+
+// backoff computes the jittered delay before the attempt following the
+// zero-indexed attempt n: min(MaxInterval, InitialInterval*Multiplier^n),
+// jittered uniformly within ±RandomizationFactor.
+func (p *RetryPolicy) backoff(n int) time.Duration {
+	d := float64(p.InitialInterval) * math.Pow(p.Multiplier, float64(n))
+	if p.MaxInterval > 0 && d > float64(p.MaxInterval) {
+		d = float64(p.MaxInterval)
+	}
+	if p.RandomizationFactor > 0 {
+		delta := d * p.RandomizationFactor
+		d = d - delta + rand.Float64()*2*delta
+	}
+	if d < 0 {
+		d = 0
+	}
+	return time.Duration(d)
+}
+
+// retryAfterDelay parses a Retry-After header value - either a number of
+// seconds or an HTTP date - into a delay. It reports false if header is
+// empty or unparseable.
+func retryAfterDelay(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
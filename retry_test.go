@@ -0,0 +1,155 @@
+package napping
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func TestRetrySucceedsAfterFailures(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&attempts, 1)
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write([]byte(`{"Foo":1,"Bar":"ok"}`))
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	s := Session{
+		Retry: &RetryPolicy{
+			MaxAttempts:         5,
+			InitialInterval:     1 * time.Millisecond,
+			MaxInterval:         5 * time.Millisecond,
+			MaxElapsed:          time.Second,
+			Multiplier:          2,
+			RandomizationFactor: 0,
+		},
+	}
+	res := structType{}
+	start := time.Now()
+	resp, err := s.Get(url, nil, &res, nil)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 200, resp.Status())
+	assert.Equal(t, structType{Foo: 1, Bar: "ok"}, res)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	if elapsed > time.Second {
+		t.Errorf("expected retries to finish quickly, took %s", elapsed)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	s := Session{
+		Retry: &RetryPolicy{
+			MaxAttempts:     3,
+			InitialInterval: 1 * time.Millisecond,
+			MaxInterval:     1 * time.Millisecond,
+			Multiplier:      1,
+		},
+	}
+	opts := Opts{ExpectedStatus: 200}
+	_, err := s.Get(url, nil, nil, &opts)
+	if err != UnexpectedStatus {
+		t.Fatalf("expected UnexpectedStatus, got %v", err)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestRetryAfterHeaderHonored(t *testing.T) {
+	var attempts int32
+	var firstAttempt time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&attempts, 1)
+		if n == 1 {
+			firstAttempt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", MIMEApplicationJSON)
+		w.Write([]byte(`{"Foo":1,"Bar":"ok"}`))
+	}))
+	defer srv.Close()
+	url := "http://" + srv.Listener.Addr().String()
+	s := Session{
+		Retry: &RetryPolicy{
+			MaxAttempts:       2,
+			InitialInterval:   1 * time.Millisecond,
+			RespectRetryAfter: true,
+		},
+	}
+	_, err := s.Get(url, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(firstAttempt) < 900*time.Millisecond {
+		t.Errorf("expected Retry-After delay of ~1s to be honored")
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	p := &RetryPolicy{
+		InitialInterval:     100 * time.Millisecond,
+		MaxInterval:         10 * time.Second,
+		Multiplier:          2,
+		RandomizationFactor: 0.5,
+	}
+	for attempt := 0; attempt < 5; attempt++ {
+		base := float64(p.InitialInterval) * pow2(attempt)
+		if base > float64(p.MaxInterval) {
+			base = float64(p.MaxInterval)
+		}
+		lo := time.Duration(base * 0.5)
+		hi := time.Duration(base * 1.5)
+		for i := 0; i < 20; i++ {
+			d := p.backoff(attempt)
+			if d < lo || d > hi {
+				t.Fatalf("attempt %d: backoff %s out of bounds [%s, %s]", attempt, d, lo, hi)
+			}
+		}
+	}
+}
+
+func pow2(n int) float64 {
+	r := 1.0
+	for i := 0; i < n; i++ {
+		r *= 2
+	}
+	return r
+}
+
+func TestRetryAfterDelayParsing(t *testing.T) {
+	d, ok := retryAfterDelay("2")
+	if !ok || d != 2*time.Second {
+		t.Fatalf("expected 2s, got %s, %v", d, ok)
+	}
+	_, ok = retryAfterDelay("")
+	if ok {
+		t.Fatal("expected empty header to report false")
+	}
+	future := time.Now().Add(5 * time.Second).UTC().Format(http.TimeFormat)
+	d, ok = retryAfterDelay(future)
+	if !ok {
+		t.Fatal("expected HTTP-date Retry-After to parse")
+	}
+	if d <= 0 || d > 6*time.Second {
+		t.Fatalf("unexpected delay %s for %s", d, future)
+	}
+}